@@ -0,0 +1,93 @@
+package sharexhandler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// archiveContentTypes maps the supported archive extensions to their Content-Type.
+var archiveContentTypes = map[string]string{
+	".zip":    "application/zip",
+	".tar":    "application/x-tar",
+	".tar.gz": "application/gzip",
+}
+
+// archiveExtension returns the archive extension requested by name (".zip", ".tar" or ".tar.gz"),
+// or "" if name doesn't end in one of them.
+func archiveExtension(name string) string {
+	for _, ext := range []string{".tar.gz", ".tar", ".zip"} {
+		if strings.HasSuffix(name, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// writeArchive streams files as an archive of the given extension directly to w, without
+// buffering the whole archive in memory.
+func writeArchive(w http.ResponseWriter, id string, files []FileInfo, ext string) error {
+	w.Header().Set("Content-Type", archiveContentTypes[ext])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(dispositionValueFormat, "attachment", id+ext))
+	w.WriteHeader(http.StatusOK)
+
+	switch ext {
+	case ".zip":
+		return writeZipArchive(w, files)
+	case ".tar":
+		return writeTarArchive(w, files)
+	case ".tar.gz":
+		gzipWriter := gzip.NewWriter(w)
+		defer gzipWriter.Close()
+		return writeTarArchive(gzipWriter, files)
+	default:
+		return nil
+	}
+}
+
+func writeZipArchive(w io.Writer, files []FileInfo) error {
+	zipWriter := zip.NewWriter(w)
+	defer zipWriter.Close()
+	for _, file := range files {
+		entryWriter, err := zipWriter.Create(file.Name)
+		if err != nil {
+			return err
+		}
+		if err := copyFile(entryWriter, file); err != nil {
+			return err
+		}
+	}
+	return zipWriter.Close()
+}
+
+func writeTarArchive(w io.Writer, files []FileInfo) error {
+	tarWriter := tar.NewWriter(w)
+	defer tarWriter.Close()
+	for _, file := range files {
+		if err := tarWriter.WriteHeader(&tar.Header{
+			Name: file.Name,
+			Size: file.Size,
+			Mode: 0644,
+		}); err != nil {
+			return err
+		}
+		if err := copyFile(tarWriter, file); err != nil {
+			return err
+		}
+	}
+	return tarWriter.Close()
+}
+
+func copyFile(w io.Writer, file FileInfo) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = io.Copy(w, reader)
+	return err
+}
@@ -0,0 +1,91 @@
+package sharexhandler
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// ClamAVScanner scans uploads using a clamd daemon's INSTREAM command, reachable over TCP or a
+// unix socket.
+type ClamAVScanner struct {
+	// Network is "tcp" or "unix".
+	Network string
+	// Address is the clamd address, e.g. "127.0.0.1:3310" or "/var/run/clamav/clamd.ctl".
+	Address string
+	// DialTimeout bounds connecting to clamd. Defaults to 5 seconds if zero.
+	DialTimeout time.Duration
+	// ChunkSize bounds how much is buffered per INSTREAM chunk. Defaults to 4096 if zero.
+	ChunkSize int
+}
+
+const clamAVMaxChunkSize = 4096
+
+func (scanner *ClamAVScanner) Scan(ctx context.Context, reader io.Reader) (ScanResult, error) {
+	dialTimeout := scanner.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = 5 * time.Second
+	}
+	chunkSize := scanner.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = clamAVMaxChunkSize
+	}
+
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, scanner.Network, scanner.Address)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return ScanResult{}, err
+	}
+
+	buf := make([]byte, chunkSize)
+	sizeBuf := make([]byte, 4)
+	for {
+		n, readErr := reader.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(sizeBuf, uint32(n))
+			if _, err := conn.Write(sizeBuf); err != nil {
+				return ScanResult{}, err
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return ScanResult{}, err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return ScanResult{}, readErr
+		}
+	}
+	// Zero-length chunk terminates the stream.
+	binary.BigEndian.PutUint32(sizeBuf, 0)
+	if _, err := conn.Write(sizeBuf); err != nil {
+		return ScanResult{}, err
+	}
+
+	response, err := bufio.NewReader(conn).ReadString('\x00')
+	if err != nil && err != io.EOF {
+		return ScanResult{}, err
+	}
+	response = strings.TrimRight(response, "\x00\r\n")
+
+	switch {
+	case strings.HasSuffix(response, "OK"):
+		return ScanResult{Status: ScanStatusClean}, nil
+	case strings.Contains(response, "FOUND"):
+		signature := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(response, "stream:"), "FOUND"))
+		return ScanResult{Status: ScanStatusInfected, Signature: signature}, nil
+	default:
+		return ScanResult{}, fmt.Errorf("sharexhandler: unexpected clamd response: %q", response)
+	}
+}
@@ -0,0 +1,95 @@
+package sharexhandler
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"regexp"
+)
+
+// IDGenerator produces the short ids entries are stored and served under.
+type IDGenerator interface {
+	Generate() (string, error)
+}
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// base58Alphabet is the Bitcoin alphabet: base62 minus the visually ambiguous "0", "O", "I" and "l".
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// Base62Generator produces random ids from the alphanumeric alphabet (0-9, A-Z, a-z).
+type Base62Generator struct {
+	Length int
+}
+
+func (generator *Base62Generator) Generate() (string, error) {
+	return randomAlphabetString(base62Alphabet, generator.Length)
+}
+
+// Base58Generator produces random ids from the Bitcoin base58 alphabet, which avoids characters
+// that are easily confused with one another when read aloud or typed by hand.
+type Base58Generator struct {
+	Length int
+}
+
+func (generator *Base58Generator) Generate() (string, error) {
+	return randomAlphabetString(base58Alphabet, generator.Length)
+}
+
+func randomAlphabetString(alphabet string, length int) (string, error) {
+	alphabetSize := big.NewInt(int64(len(alphabet)))
+	result := make([]byte, length)
+	for i := range result {
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+		result[i] = alphabet[n.Int64()]
+	}
+	return string(result), nil
+}
+
+// wordListAdjectives and wordListNouns back WordPairGenerator. They're intentionally short; a
+// numeric suffix keeps the combined space large enough to avoid frequent collisions.
+var wordListAdjectives = []string{
+	"quick", "lazy", "happy", "brave", "calm", "eager", "gentle", "jolly",
+	"kind", "lively", "proud", "silly", "witty", "bold", "quiet", "swift",
+}
+var wordListNouns = []string{
+	"otter", "falcon", "tiger", "whale", "panda", "eagle", "fox", "wolf",
+	"heron", "lynx", "raven", "shark", "moose", "hawk", "bear", "crane",
+}
+
+// WordPairGenerator produces human-friendly ids like "happy-otter-42".
+type WordPairGenerator struct{}
+
+func (generator *WordPairGenerator) Generate() (string, error) {
+	adjective, err := pickWord(wordListAdjectives)
+	if err != nil {
+		return "", err
+	}
+	noun, err := pickWord(wordListNouns)
+	if err != nil {
+		return "", err
+	}
+	suffix, err := rand.Int(rand.Reader, big.NewInt(100))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v-%v-%v", adjective, noun, suffix), nil
+}
+
+func pickWord(words []string) (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(words))))
+	if err != nil {
+		return "", err
+	}
+	return words[n.Int64()], nil
+}
+
+// vanityNamePattern restricts user-chosen ids (via ?name=) to a safe, URL-friendly character set.
+var vanityNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+func isValidVanityName(name string) bool {
+	return vanityNamePattern.MatchString(name)
+}
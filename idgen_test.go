@@ -0,0 +1,62 @@
+package sharexhandler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsValidVanityName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"", false},
+		{"abc", true},
+		{"abc-123_XYZ", true},
+		{"has space", false},
+		{"has/slash", false},
+		{"has.dot", false},
+		{"../traversal", false},
+		{string(make([]byte, 64)), false}, // NUL bytes aren't in the allowed set
+	}
+	for _, tt := range tests {
+		if got := isValidVanityName(tt.name); got != tt.want {
+			t.Errorf("isValidVanityName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// A name made entirely of valid characters at the length boundary (64) must pass, and one
+	// character over must fail.
+	valid64 := ""
+	for i := 0; i < 64; i++ {
+		valid64 += "a"
+	}
+	if !isValidVanityName(valid64) {
+		t.Errorf("isValidVanityName(64-char name) = false, want true")
+	}
+	if isValidVanityName(valid64 + "a") {
+		t.Errorf("isValidVanityName(65-char name) = true, want false")
+	}
+}
+
+func TestRandomAlphabetString(t *testing.T) {
+	for _, alphabet := range []string{base62Alphabet, base58Alphabet} {
+		s, err := randomAlphabetString(alphabet, 16)
+		if err != nil {
+			t.Fatalf("randomAlphabetString(%q, 16) error = %v", alphabet, err)
+		}
+		if len(s) != 16 {
+			t.Fatalf("randomAlphabetString(%q, 16) length = %v, want 16", alphabet, len(s))
+		}
+		for _, r := range s {
+			if !strings.ContainsRune(alphabet, r) {
+				t.Fatalf("randomAlphabetString(%q, 16) = %q contains character %q not in alphabet", alphabet, s, r)
+			}
+		}
+	}
+
+	s, err := randomAlphabetString(base62Alphabet, 0)
+	if err != nil || s != "" {
+		t.Fatalf("randomAlphabetString(alphabet, 0) = (%q, %v), want (\"\", nil)", s, err)
+	}
+}
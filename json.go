@@ -0,0 +1,59 @@
+package sharexhandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// jsonpCallbackPattern restricts the "?callback=" parameter to a safe JavaScript identifier
+// before it is reflected into the response body, the same way idgen.go's vanityNamePattern
+// restricts user-chosen ids.
+var jsonpCallbackPattern = regexp.MustCompile(`^[\w$.\[\]]+$`)
+
+// uploadResponse is the JSON body written for uploads that ask for a structured response, either
+// via "Accept: application/json" or "?format=json".
+type uploadResponse struct {
+	URL                string `json:"url"`
+	Id                 string `json:"id"`
+	Filename           string `json:"filename"`
+	ContentType        string `json:"contentType"`
+	Size               int64  `json:"size"`
+	DeleteUrl          string `json:"deleteUrl"`
+	DeleteToken        string `json:"deleteToken"`
+	ExpiresAt          string `json:"expiresAt,omitempty"`
+	RemainingDownloads int    `json:"remainingDownloads,omitempty"`
+}
+
+// wantsJSON reports whether req asked for a JSON upload response instead of the plaintext URL
+// ShareX expects by default.
+func wantsJSON(req *http.Request) bool {
+	if req.URL.Query().Get("format") == "json" {
+		return true
+	}
+	for _, value := range strings.Split(req.Header.Get("Accept"), ",") {
+		if strings.EqualFold(strings.TrimSpace(value), "application/json") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJson writes v as a JSON response, wrapping it as a JSONP callback when the request carries
+// a "?callback=" parameter.
+func writeJson(w http.ResponseWriter, req *http.Request, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if callback := req.URL.Query().Get("callback"); callback != "" && jsonpCallbackPattern.MatchString(callback) {
+		w.Header().Set("Content-Type", "application/javascript")
+		_, err = fmt.Fprintf(w, "%v(%v)", callback, string(body))
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(body)
+	return err
+}
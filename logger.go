@@ -0,0 +1,23 @@
+package sharexhandler
+
+import "net/http"
+
+// Logger receives errors that would otherwise have caused a panic inside an HTTP handler.
+// Implementations can wrap the standard library's log.Logger, a structured logger, or discard
+// output entirely.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// logf writes a formatted message to the configured Logger, if any. It is a no-op when Logger is nil.
+func (shareXHandler *ShareXHandler) logf(format string, v ...interface{}) {
+	if shareXHandler.Logger != nil {
+		shareXHandler.Logger.Printf(format, v...)
+	}
+}
+
+// internalError writes a 500 response and reports err to the configured Logger.
+func (shareXHandler *ShareXHandler) internalError(w http.ResponseWriter, err error) {
+	http.Error(w, "500 an internal error occurred", http.StatusInternalServerError)
+	shareXHandler.logf("sharexhandler: %v", err)
+}
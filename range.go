@@ -0,0 +1,62 @@
+package sharexhandler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// errNoRange is returned when the Range header is absent or doesn't parse as a byte-range.
+var errNoRange = errors.New("sharexhandler: no range requested")
+
+// errUnsatisfiableRange is returned when the requested range falls outside [0, size).
+var errUnsatisfiableRange = errors.New("sharexhandler: range not satisfiable")
+
+// parseByteRange parses a "bytes=start-end" Range header value against a resource of the given
+// size. It supports open-ended ranges ("bytes=100-") and suffix ranges ("bytes=-500"). Only the
+// first range of a (possibly multi-range) header is honored; additional ranges are ignored.
+func parseByteRange(header string, size int64) (start, end int64, err error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return 0, 0, errNoRange
+	}
+	spec := strings.TrimPrefix(header, prefix)
+	if comma := strings.Index(spec, ","); comma != -1 {
+		spec = spec[:comma]
+	}
+	dash := strings.Index(spec, "-")
+	if dash == -1 {
+		return 0, 0, errNoRange
+	}
+	startStr, endStr := spec[:dash], spec[dash+1:]
+	switch {
+	case startStr == "" && endStr == "":
+		return 0, 0, errNoRange
+	case startStr == "":
+		n, convErr := strconv.ParseInt(endStr, 10, 64)
+		if convErr != nil || n <= 0 || size == 0 {
+			return 0, 0, errUnsatisfiableRange
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, nil
+	default:
+		start, err = strconv.ParseInt(startStr, 10, 64)
+		if err != nil || start < 0 {
+			return 0, 0, errUnsatisfiableRange
+		}
+		if endStr == "" {
+			end = size - 1
+		} else if end, err = strconv.ParseInt(endStr, 10, 64); err != nil || end < start {
+			return 0, 0, errUnsatisfiableRange
+		}
+	}
+	if start >= size {
+		return 0, 0, errUnsatisfiableRange
+	}
+	if end >= size {
+		end = size - 1
+	}
+	return start, end, nil
+}
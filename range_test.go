@@ -0,0 +1,46 @@
+package sharexhandler
+
+import "testing"
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		size      int64
+		wantStart int64
+		wantEnd   int64
+		wantErr   error
+	}{
+		{"no header", "", 100, 0, 0, errNoRange},
+		{"not a byte range", "items=0-5", 100, 0, 0, errNoRange},
+		{"open start and end", "bytes=-", 100, 0, 0, errNoRange},
+		{"simple range", "bytes=0-99", 100, 0, 99, nil},
+		{"mid range", "bytes=10-20", 100, 10, 20, nil},
+		{"open-ended range", "bytes=50-", 100, 50, 99, nil},
+		{"end clamped to size", "bytes=50-1000", 100, 50, 99, nil},
+		{"suffix range", "bytes=-10", 100, 90, 99, nil},
+		{"suffix range larger than size", "bytes=-1000", 100, 0, 99, nil},
+		{"suffix range on zero-size resource", "bytes=-5", 0, 0, 0, errUnsatisfiableRange},
+		{"suffix range with zero length", "bytes=-0", 100, 0, 0, errUnsatisfiableRange},
+		{"start beyond size", "bytes=100-200", 100, 0, 0, errUnsatisfiableRange},
+		{"start at size", "bytes=100-", 100, 0, 0, errUnsatisfiableRange},
+		{"end before start", "bytes=20-10", 100, 0, 0, errUnsatisfiableRange},
+		{"multi-range honors first", "bytes=0-9,20-29", 100, 0, 9, nil},
+		{"non-numeric start", "bytes=abc-20", 100, 0, 0, errUnsatisfiableRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := parseByteRange(tt.header, tt.size)
+			if err != tt.wantErr {
+				t.Fatalf("parseByteRange(%q, %v) error = %v, want %v", tt.header, tt.size, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Fatalf("parseByteRange(%q, %v) = (%v, %v), want (%v, %v)", tt.header, tt.size, start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
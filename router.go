@@ -1,14 +1,18 @@
 package sharexhandler
 
 import (
+	"context"
+	"crypto/subtle"
+	"fmt"
 	"github.com/gorilla/mux"
-	"net/http"
+	"hash"
 	"io"
 	"mime"
 	"mime/multipart"
-	"bytes"
+	"net/http"
+	"strconv"
 	"strings"
-	"fmt"
+	"time"
 )
 
 // Path configuration:
@@ -16,6 +20,7 @@ import (
 type PathConfiguration struct {
 	UploadPath string // Path where POST-Requests of ShareX are routing at. Example: /upload
 	GetPath    string // Path where clients get their files. The Id in the path must be {id}. Example: /get/{id}
+	DeletePath string // Path where uploaders can delete their file again. The Id and token in the path must be {id} and {token}. Example: /delete/{id}/{token}
 }
 
 // This is the main class which is used to use the ShareX handler
@@ -34,6 +39,69 @@ type ShareXHandler struct {
 	ProtocolHost string
 	// Whitelisted content types which will be displayed in the client`s browser.
 	WhitelistedContentTypes []string
+	// Scanners are run against every upload to check for malware. Scanning happens in a
+	// background goroutine that is started just before the upload response is written and runs
+	// concurrently with it, unless SyncScan is true.
+	Scanners []Scanner
+	// SyncScan makes handleUploadRequest wait for scanning to finish before responding, instead
+	// of scanning in the background. Per default this is false.
+	SyncScan bool
+	// InfectedStatusCode is the HTTP status returned by handleGetRequest for entries flagged as
+	// infected. Per default this is 451 (Unavailable For Legal Reasons).
+	InfectedStatusCode int
+	// IDGenerator produces the ids new uploads are stored under. Per default this is a
+	// Base62Generator of length 8.
+	IDGenerator IDGenerator
+	// MaxUploadSize bounds the size in bytes of a single uploaded file. Requests exceeding it
+	// are rejected with 413 Request Entity Too Large. Zero (the default) means unbounded.
+	MaxUploadSize int64
+	// HashAlgorithm produces the hash used to compute a file's strong ETag while it is written.
+	// Per default this is sha256.New.
+	HashAlgorithm func() hash.Hash
+	// Logger receives errors encountered while handling requests. Per default errors are
+	// discarded.
+	Logger Logger
+	// MaxTTL caps how far in the future an uploader may set an entry's expiry via the Max-Days
+	// header. Zero (the default) means uploaders may request any TTL, including none.
+	MaxTTL time.Duration
+	// MaxDownloads caps how many downloads an uploader may allow via the Max-Downloads header.
+	// Zero (the default) means uploaders may request any limit, including none.
+	MaxDownloads int
+}
+
+// maxIDAttempts bounds how many times handleUploadRequest retries id generation on collision
+// before giving up.
+const maxIDAttempts = 5
+
+// allocateID picks the id a new upload will be stored under: the caller's requested vanity name
+// if valid and free, otherwise a generated id retried up to maxIDAttempts times on collision.
+func (shareXHandler *ShareXHandler) allocateID(req *http.Request) (string, error) {
+	if name := req.URL.Query().Get("name"); isValidVanityName(name) {
+		if exists, err := shareXHandler.Storage.Exists(name); err != nil {
+			return "", err
+		} else if !exists {
+			return name, nil
+		}
+	}
+
+	generator := shareXHandler.IDGenerator
+	if generator == nil {
+		generator = &Base62Generator{Length: 8}
+	}
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		id, err := generator.Generate()
+		if err != nil {
+			return "", err
+		}
+		exists, err := shareXHandler.Storage.Exists(id)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("sharexhandler: could not allocate a free id after %v attempts", maxIDAttempts)
 }
 
 // This is the function which binds a ShareX handler router to the given path.
@@ -41,6 +109,9 @@ func (shareXHandler *ShareXHandler) BindToRouter(parentRouter *mux.Router) {
 	router := parentRouter.PathPrefix(shareXHandler.Path).Subrouter()
 	router.HandleFunc(shareXHandler.PathConfiguration.UploadPath, shareXHandler.handleUploadRequest)
 	router.HandleFunc(shareXHandler.PathConfiguration.GetPath, shareXHandler.handleGetRequest)
+	if shareXHandler.PathConfiguration.DeletePath != "" {
+		router.HandleFunc(shareXHandler.PathConfiguration.DeletePath, shareXHandler.handleDeleteRequest)
+	}
 }
 
 // This method handles incoming POST upload request.
@@ -48,85 +119,300 @@ func (shareXHandler *ShareXHandler) handleUploadRequest(w http.ResponseWriter, r
 	if shareXHandler.OutgoingFunction != nil {
 		shareXHandler.OutgoingFunction(w, req)
 	}
-	var err error
 	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
 	if err != nil {
 		http.Error(w, "400 bad request", http.StatusBadRequest)
-	} else {
-		multipartReader := multipart.NewReader(req.Body, params["boundary"])
-		entry := shareXHandler.Storage.NewStorageEntry()
-		if err := entry.Save(); err != nil {
-			http.Error(w, "500 an internal error occurred", http.StatusInternalServerError)
-			panic(err)
+		return
+	}
+	multipartReader := multipart.NewReader(req.Body, params["boundary"])
+	id, err := shareXHandler.allocateID(req)
+	if err != nil {
+		shareXHandler.internalError(w, err)
+		return
+	}
+	entry := shareXHandler.Storage.NewStorageEntry(id)
+	if err := entry.Save(); err != nil {
+		shareXHandler.internalError(w, err)
+		return
+	}
+
+	fileCount := 0
+	for {
+		part, partErr := multipartReader.NextPart()
+		if partErr == io.EOF {
+			break
+		}
+		if partErr != nil {
+			shareXHandler.internalError(w, partErr)
+			return
+		}
+		contentType := part.Header.Get("Content-Type")
+		filename := part.FileName()
+		fileCount++
+		fileWriter, err := entry.AddFile(filename, contentType)
+		if err != nil {
+			shareXHandler.internalError(w, err)
+			return
+		}
+
+		sniffedContentType, etag, err := shareXHandler.copyPart(fileWriter, part, contentType)
+		fileWriter.Close()
+		if err == errUploadTooLarge {
+			shareXHandler.rejectUpload(w, id, "413 request entity too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			shareXHandler.internalError(w, err)
+			return
+		}
+		if sniffedContentType != "" {
+			contentType = sniffedContentType
+		}
+
+		if fileCount == 1 {
+			// Mirrored onto the entry itself so single-file entries keep working unchanged.
+			entry.SetContentType(contentType)
+			entry.SetFilename(filename)
+			entry.SetETagValue(etag)
+		}
+	}
+	if fileCount == 0 {
+		shareXHandler.rejectUpload(w, id, "400 bad request", http.StatusBadRequest)
+		return
+	}
+
+	deleteToken, tokenErr := generateDeleteToken()
+	if tokenErr != nil {
+		shareXHandler.internalError(w, tokenErr)
+		return
+	}
+	entry.SetDeleteToken(deleteToken)
+	expiresAt, maxDownloads := shareXHandler.resolveExpiry(req)
+	if !expiresAt.IsZero() {
+		entry.SetExpiry(expiresAt)
+	}
+	if maxDownloads > 0 {
+		entry.SetMaxDownloads(maxDownloads)
+	}
+	if err := entry.Update(); err != nil {
+		shareXHandler.internalError(w, err)
+		return
+	}
+
+	if len(shareXHandler.Scanners) > 0 {
+		if shareXHandler.SyncScan {
+			shareXHandler.scanEntry(entry)
 		} else {
-			id := entry.GetId()
-			if writer, err := entry.GetWriter(); err != nil {
-				http.Error(w, "500 an internal error occurred", http.StatusInternalServerError)
-				panic(err)
-			} else {
-				defer writer.Close()
-				var partErr error
-				var part *multipart.Part
-				part, partErr = multipartReader.NextPart()
-				if partErr != nil {
-					http.Error(w, "500 an internal error occurred", http.StatusInternalServerError)
-					panic(partErr)
-				} else {
-					buf := new(bytes.Buffer)
-					entry.SetContentType(part.Header.Get("Content-Type"))
-					entry.SetFilename(part.FileName())
-					for ; ; {
-						if partErr == nil {
-							buf.Reset()
-							if _, err := io.Copy(buf, part); err != nil {
-								http.Error(w, "500 an internal error occurred", http.StatusInternalServerError)
-								panic(err)
-							} else {
-								writer.Write(buf.Bytes())
-							}
-						} else if partErr != io.EOF {
-							http.Error(w, "500 an internal error occurred", http.StatusInternalServerError)
-							panic(partErr)
-						} else {
-							break
-						}
-						part, partErr = multipartReader.NextPart()
-					}
-					buf.Reset()
-					if err := entry.Update(); err != nil {
-						http.Error(w, "500 an internal error occurred", http.StatusInternalServerError)
-						panic(partErr)
-					} else {
-						w.WriteHeader(200)
-						url := shareXHandler.ProtocolHost + id + entry.GetFilename()[strings.LastIndex(entry.GetFilename(), "."):]
-						w.Write([]byte(url))
-					}
-				}
+			entry.SetScanStatus(ScanStatusPending)
+			if err := entry.Update(); err != nil {
+				shareXHandler.internalError(w, err)
+				return
 			}
+			go shareXHandler.scanEntry(entry)
+		}
+	}
+
+	filename, contentType := entry.GetFilename(), entry.GetContentType()
+	if fileCount > 1 {
+		filename, contentType = id+".zip", archiveContentTypes[".zip"]
+	}
+	url := shareXHandler.ProtocolHost + id + filename[strings.LastIndex(filename, "."):]
+	if wantsJSON(req) {
+		deleteUrl := ""
+		if shareXHandler.PathConfiguration.DeletePath != "" {
+			replacer := strings.NewReplacer("{id}", id, "{token}", deleteToken)
+			deleteUrl = shareXHandler.ProtocolHost + strings.TrimPrefix(replacer.Replace(shareXHandler.PathConfiguration.DeletePath), "/")
 		}
+		response := uploadResponse{
+			URL:         url,
+			Id:          id,
+			Filename:    filename,
+			ContentType: contentType,
+			Size:        entry.GetSize(),
+			DeleteUrl:   deleteUrl,
+			DeleteToken: deleteToken,
+		}
+		if !expiresAt.IsZero() {
+			response.ExpiresAt = expiresAt.Format(time.RFC3339)
+		}
+		if maxDownloads > 0 {
+			response.RemainingDownloads = maxDownloads
+		}
+		if err := writeJson(w, req, response); err != nil {
+			shareXHandler.logf("sharexhandler: %v", err)
+		}
+	} else {
+		w.WriteHeader(200)
+		w.Write([]byte(url))
 	}
 }
 
-var dispositionValueFormat = "%v; filename=\"%v\""
+// rejectUpload deletes the entry allocated for a rejected upload, since it was otherwise left
+// behind with no expiry set, then writes message/statusCode as the response. Used for rejections
+// a client can trigger on demand (oversized or empty uploads), not exceptional server errors.
+func (shareXHandler *ShareXHandler) rejectUpload(w http.ResponseWriter, id string, message string, statusCode int) {
+	if err := shareXHandler.Storage.DeleteStorageEntry(id); err != nil {
+		shareXHandler.logf("sharexhandler: %v", err)
+	}
+	http.Error(w, message, statusCode)
+}
 
-// This method handles get requests and shares files.
-func (shareXHandler *ShareXHandler) handleGetRequest(w http.ResponseWriter, req *http.Request) {
+// This method handles requests to delete a previously uploaded entry, authenticated by the
+// per-upload delete token handed back at upload time.
+func (shareXHandler *ShareXHandler) handleDeleteRequest(w http.ResponseWriter, req *http.Request) {
 	if shareXHandler.OutgoingFunction != nil {
 		shareXHandler.OutgoingFunction(w, req)
 	}
 	vars := mux.Vars(req)
 	id := vars["id"]
-	id = id[:strings.LastIndex(id, ".")]
 	if success, err, entry := shareXHandler.Storage.LoadStorageEntry(id); err != nil {
-		http.Error(w, "500 an internal error occurred", http.StatusInternalServerError)
-		panic(err)
+		shareXHandler.internalError(w, err)
 	} else if !success {
 		http.NotFound(w, req)
-	} else if req.Header.Get("If-None-Match") == entry.GetETagValue() {
+	} else if subtle.ConstantTimeCompare([]byte(vars["token"]), []byte(entry.GetDeleteToken())) != 1 {
+		http.Error(w, "403 forbidden", http.StatusForbidden)
+	} else if err := shareXHandler.Storage.DeleteStorageEntry(id); err != nil {
+		shareXHandler.internalError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// serveArchive streams entry's files as an archive of the given extension (".zip", ".tar" or
+// ".tar.gz"). The caller is responsible for checking that entry is actually eligible to be
+// downloaded (scan status, expiry, download cap) before calling this.
+func (shareXHandler *ShareXHandler) serveArchive(w http.ResponseWriter, id string, entry StorageEntry, ext string) {
+	files, err := entry.ListFiles()
+	if err != nil {
+		shareXHandler.internalError(w, err)
+		return
+	}
+	if err := writeArchive(w, id, files, ext); err != nil {
+		shareXHandler.logf("sharexhandler: %v", err)
+	}
+}
+
+// scanEntry runs the configured Scanners against every file of entry (AddFile is used to store
+// all parts of an upload, including the single-file case, so ListFiles covers them all) and
+// persists the resulting ScanStatus. Scan errors are recorded as ScanStatusError rather than
+// failing the upload.
+func (shareXHandler *ShareXHandler) scanEntry(entry StorageEntry) {
+	files, err := entry.ListFiles()
+	if err != nil {
+		entry.SetScanStatus(ScanStatusError)
+		entry.Update()
+		return
+	}
+	status := ScanStatusClean
+	for _, file := range files {
+		result, err := shareXHandler.scanFile(file)
+		if err != nil {
+			status = ScanStatusError
+			break
+		}
+		if result.Status == ScanStatusInfected {
+			status = result.Status
+			break
+		}
+	}
+	entry.SetScanStatus(status)
+	entry.Update()
+}
+
+// scanFile runs the configured Scanners against a single file, reopening it fresh for each
+// scanner since FileInfo.Open only returns a non-seekable reader.
+func (shareXHandler *ShareXHandler) scanFile(file FileInfo) (ScanResult, error) {
+	for _, scanner := range shareXHandler.Scanners {
+		reader, err := file.Open()
+		if err != nil {
+			return ScanResult{Status: ScanStatusError}, err
+		}
+		result, err := scanner.Scan(context.Background(), reader)
+		reader.Close()
+		if err != nil {
+			return ScanResult{Status: ScanStatusError}, err
+		}
+		if result.Status == ScanStatusInfected {
+			return result, nil
+		}
+	}
+	return ScanResult{Status: ScanStatusClean}, nil
+}
+
+var dispositionValueFormat = "%v; filename=\"%v\""
+
+// This method handles get requests and shares files.
+func (shareXHandler *ShareXHandler) handleGetRequest(w http.ResponseWriter, req *http.Request) {
+	if shareXHandler.OutgoingFunction != nil {
+		shareXHandler.OutgoingFunction(w, req)
+	}
+	vars := mux.Vars(req)
+	rawId := vars["id"]
+	// The archive extension is only meaningful for entries that actually have more than one
+	// file; a single-file entry whose own filename happens to end in ".zip" is just a regular
+	// download and must not be routed into the archive branch below.
+	archiveExt := archiveExtension(rawId)
+	id := rawId
+	if archiveExt != "" {
+		id = strings.TrimSuffix(rawId, archiveExt)
+	} else {
+		id = id[:strings.LastIndex(id, ".")]
+	}
+	success, err, entry := shareXHandler.Storage.LoadStorageEntry(id)
+	if err != nil {
+		shareXHandler.internalError(w, err)
+		return
+	}
+	if !success {
+		http.NotFound(w, req)
+		return
+	}
+	// Scan status is enforced before the archive branch below so a multi-file entry's download
+	// can't bypass the infected/pending checks that apply to single-file entries.
+	if entry.GetScanStatus() == ScanStatusInfected {
+		statusCode := shareXHandler.InfectedStatusCode
+		if statusCode == 0 {
+			statusCode = http.StatusUnavailableForLegalReasons
+		}
+		http.Error(w, "file flagged as infected", statusCode)
+		return
+	}
+	if entry.GetScanStatus() == ScanStatusPending && wantsJSON(req) {
+		w.WriteHeader(http.StatusAccepted)
+		writeJson(w, req, map[string]string{"status": string(ScanStatusPending)})
+		return
+	}
+	// Expiry and the download cap are enforced, and IncrementDownloads is called, before the
+	// archive branch below so archive downloads of multi-file entries are gated and counted the
+	// same way single-file downloads are.
+	if expiry := entry.GetExpiry(); !expiry.IsZero() && time.Now().After(expiry) {
+		http.Error(w, "410 gone", http.StatusGone)
+		return
+	}
+	allowed, err := entry.IncrementDownloads()
+	if err != nil {
+		shareXHandler.internalError(w, err)
+		return
+	}
+	if !allowed {
+		http.Error(w, "410 gone", http.StatusGone)
+		return
+	}
+	if archiveExt != "" {
+		files, err := entry.ListFiles()
+		if err != nil {
+			shareXHandler.internalError(w, err)
+			return
+		}
+		if len(files) > 1 {
+			shareXHandler.serveArchive(w, id, entry, archiveExt)
+			return
+		}
+	}
+	if req.Header.Get("If-None-Match") == entry.GetETagValue() {
 		w.WriteHeader(http.StatusNotModified)
 	} else if reader, err := entry.GetReader(); err != nil {
-		http.Error(w, "500 an internal error occurred", http.StatusInternalServerError)
-		panic(err)
+		shareXHandler.internalError(w, err)
 	} else {
 		// content-disposition: inline; filename="javaw_2017-07-10_18-29-32.png"
 		// content-disposition: attachment; filename="temp.html"
@@ -140,20 +426,51 @@ func (shareXHandler *ShareXHandler) handleGetRequest(w http.ResponseWriter, req
 	inlinePassed:
 		w.Header().Set("Content-Type", entry.GetContentType())
 		w.Header().Set("ETag", entry.GetETagValue())
-		w.WriteHeader(http.StatusOK)
-		buf := make([]byte, shareXHandler.BufferSize)
-		for {
-			n, err := reader.Read(buf)
-			if err != nil && err != io.EOF {
-				http.Error(w, "500 an internal error occurred", http.StatusInternalServerError)
-				panic(err)
-			}
-			if n == 0 {
-				break
-			}
-			if _, err := w.Write(buf[:n]); err != nil {
-				panic(err)
+		w.Header().Set("Accept-Ranges", "bytes")
+
+		size := entry.GetSize()
+		rangeHeader := req.Header.Get("Range")
+		if ifRange := req.Header.Get("If-Range"); ifRange != "" && ifRange != entry.GetETagValue() {
+			rangeHeader = ""
+		}
+		start, end, rangeErr := parseByteRange(rangeHeader, size)
+		if rangeHeader != "" && rangeErr == errUnsatisfiableRange {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%v", size))
+			http.Error(w, "416 requested range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		if rangeErr == nil {
+			if _, err := reader.Seek(start, io.SeekStart); err != nil {
+				shareXHandler.internalError(w, err)
+				return
 			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %v-%v/%v", start, end, size))
+			w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+			w.WriteHeader(http.StatusPartialContent)
+			shareXHandler.copyBuffered(w, io.LimitReader(reader, end-start+1))
+		} else {
+			w.WriteHeader(http.StatusOK)
+			shareXHandler.copyBuffered(w, reader)
+		}
+	}
+}
+
+// copyBuffered streams src to w using the handler's configured BufferSize. Errors can no longer
+// be turned into an HTTP status since headers have already been sent, so they're only logged.
+func (shareXHandler *ShareXHandler) copyBuffered(w http.ResponseWriter, src io.Reader) {
+	buf := make([]byte, shareXHandler.BufferSize)
+	for {
+		n, err := src.Read(buf)
+		if err != nil && err != io.EOF {
+			shareXHandler.logf("sharexhandler: %v", err)
+			return
+		}
+		if n == 0 {
+			break
+		}
+		if _, err := w.Write(buf[:n]); err != nil {
+			shareXHandler.logf("sharexhandler: %v", err)
+			return
 		}
 	}
 }
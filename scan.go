@@ -0,0 +1,29 @@
+package sharexhandler
+
+import (
+	"context"
+	"io"
+)
+
+// ScanStatus is the outcome of scanning an uploaded entry for malware.
+type ScanStatus string
+
+const (
+	ScanStatusPending  ScanStatus = "pending"
+	ScanStatusClean    ScanStatus = "clean"
+	ScanStatusInfected ScanStatus = "infected"
+	ScanStatusError    ScanStatus = "error"
+)
+
+// ScanResult is the verdict a Scanner reaches for a single upload.
+type ScanResult struct {
+	Status ScanStatus
+	// Signature is the name of the threat found, if any. Empty for clean/error results.
+	Signature string
+}
+
+// Scanner inspects uploaded content for malware. Implementations may call out to an antivirus
+// daemon (see ClamAVScanner) or a third-party HTTP API (see WebhookScanner).
+type Scanner interface {
+	Scan(ctx context.Context, reader io.Reader) (ScanResult, error)
+}
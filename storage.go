@@ -0,0 +1,82 @@
+package sharexhandler
+
+import (
+	"io"
+	"time"
+)
+
+// Storage is the backend contract used by ShareXHandler to persist and retrieve uploaded files.
+// Implementations are free to back this with the filesystem, a database, object storage, etc.
+type Storage interface {
+	// NewStorageEntry allocates a new, empty StorageEntry under the given id. The entry must be
+	// persisted with Save() before it can be loaded again via LoadStorageEntry.
+	NewStorageEntry(id string) StorageEntry
+	// LoadStorageEntry loads the entry identified by id. success is false (with a nil err) if no
+	// such entry exists.
+	LoadStorageEntry(id string) (success bool, err error, entry StorageEntry)
+	// DeleteStorageEntry permanently removes the entry identified by id.
+	DeleteStorageEntry(id string) error
+	// Exists reports whether an entry is already stored under id, used to detect id collisions
+	// before committing a new upload to it.
+	Exists(id string) (bool, error)
+	// ListExpired returns the ids of every entry whose expiry is at or before now, for the
+	// background reaper to clean up.
+	ListExpired(now time.Time) ([]string, error)
+}
+
+// StorageEntry represents a single uploaded file tracked by a Storage backend.
+type StorageEntry interface {
+	// GetId returns the unique id this entry was stored under.
+	GetId() string
+	// Save persists a freshly created entry so it can be loaded by id.
+	Save() error
+	// Update persists changes made to the entry's metadata (filename, content type, etc.) after upload.
+	Update() error
+	// GetReader returns a seekable reader used to stream the file's content back out. It must
+	// support Seek so HTTP Range requests can jump to the requested offset instead of discarding
+	// bytes read up to it.
+	GetReader() (io.ReadSeeker, error)
+	SetFilename(filename string)
+	GetFilename() string
+	SetContentType(contentType string)
+	GetContentType() string
+	// GetSize returns the size of the stored file in bytes.
+	GetSize() int64
+	GetETagValue() string
+	// SetETagValue stores a content-addressable strong ETag (e.g. a hex hash digest) computed
+	// while the file was written, so If-None-Match works correctly across storage replicas.
+	SetETagValue(etag string)
+	// SetDeleteToken stores the secret token a client must present to delete this entry.
+	SetDeleteToken(token string)
+	GetDeleteToken() string
+	// AddFile registers an additional named file under this entry and returns a writer to stream
+	// its content into storage. Entries with more than one file are served as an archive.
+	AddFile(name string, contentType string) (io.WriteCloser, error)
+	// ListFiles returns the files stored under this entry, in the order they were added.
+	ListFiles() ([]FileInfo, error)
+	// SetScanStatus records the outcome of running this entry's content through the configured
+	// Scanners.
+	SetScanStatus(status ScanStatus)
+	GetScanStatus() ScanStatus
+	// SetExpiry records when this entry should stop being served. A zero time means it never
+	// expires.
+	SetExpiry(expiry time.Time)
+	GetExpiry() time.Time
+	// SetMaxDownloads caps how many times this entry may be downloaded. Zero means unlimited.
+	SetMaxDownloads(max int)
+	GetMaxDownloads() int
+	// GetDownloads returns how many times this entry has been downloaded so far.
+	GetDownloads() int
+	// IncrementDownloads records a download and reports whether it was allowed, i.e. the entry
+	// hadn't already reached its GetMaxDownloads() cap.
+	IncrementDownloads() (allowed bool, err error)
+}
+
+// FileInfo describes a single file within a (possibly multi-file) StorageEntry.
+type FileInfo struct {
+	Name        string
+	ContentType string
+	Size        int64
+	// Open returns a fresh reader positioned at the start of this file's content.
+	Open func() (io.ReadCloser, error)
+}
@@ -0,0 +1,16 @@
+package sharexhandler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// generateDeleteToken returns a random 32-byte, hex-encoded token used to authorize deletion of
+// an uploaded entry.
+func generateDeleteToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
@@ -0,0 +1,73 @@
+package sharexhandler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// resolveExpiry reads the client-requested Max-Days and Max-Downloads headers (ShareX custom
+// uploaders may instead send the X-ShareX-Max-Days/X-ShareX-Max-Downloads variants) and clamps
+// them against the handler's MaxTTL and MaxDownloads server-side caps.
+func (shareXHandler *ShareXHandler) resolveExpiry(req *http.Request) (expiresAt time.Time, maxDownloads int) {
+	if days, ok := headerInt(req, "Max-Days", "X-ShareX-Max-Days"); ok && days > 0 {
+		expiresAt = time.Now().Add(time.Duration(days) * 24 * time.Hour)
+	}
+	if shareXHandler.MaxTTL > 0 {
+		if cappedAt := time.Now().Add(shareXHandler.MaxTTL); expiresAt.IsZero() || expiresAt.After(cappedAt) {
+			expiresAt = cappedAt
+		}
+	}
+
+	if n, ok := headerInt(req, "Max-Downloads", "X-ShareX-Max-Downloads"); ok && n > 0 {
+		maxDownloads = n
+	}
+	if shareXHandler.MaxDownloads > 0 && (maxDownloads == 0 || maxDownloads > shareXHandler.MaxDownloads) {
+		maxDownloads = shareXHandler.MaxDownloads
+	}
+	return expiresAt, maxDownloads
+}
+
+// headerInt returns the value of the first of names present on req that parses as an integer.
+// Zero and negative values are returned as parsed; headers that don't parse are skipped.
+func headerInt(req *http.Request, names ...string) (int, bool) {
+	for _, name := range names {
+		if value := req.Header.Get(name); value != "" {
+			if n, err := strconv.Atoi(value); err == nil {
+				return n, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// StartReaper launches a background goroutine that periodically deletes expired entries. It
+// stops once ctx is done.
+func (shareXHandler *ShareXHandler) StartReaper(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				shareXHandler.reapExpired()
+			}
+		}
+	}()
+}
+
+func (shareXHandler *ShareXHandler) reapExpired() {
+	ids, err := shareXHandler.Storage.ListExpired(time.Now())
+	if err != nil {
+		shareXHandler.logf("sharexhandler: reaper: %v", err)
+		return
+	}
+	for _, id := range ids {
+		if err := shareXHandler.Storage.DeleteStorageEntry(id); err != nil {
+			shareXHandler.logf("sharexhandler: reaper: delete %v: %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package sharexhandler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHeaderInt(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/upload", nil)
+	req.Header.Set("Max-Days", "7")
+	req.Header.Set("X-ShareX-Max-Downloads", "3")
+
+	if n, ok := headerInt(req, "Max-Days", "X-ShareX-Max-Days"); !ok || n != 7 {
+		t.Errorf("headerInt(Max-Days) = (%v, %v), want (7, true)", n, ok)
+	}
+	if n, ok := headerInt(req, "Max-Downloads", "X-ShareX-Max-Downloads"); !ok || n != 3 {
+		t.Errorf("headerInt(Max-Downloads, falls back to X-ShareX variant) = (%v, %v), want (3, true)", n, ok)
+	}
+	if _, ok := headerInt(req, "Max-Something-Else"); ok {
+		t.Errorf("headerInt(absent header) ok = true, want false")
+	}
+
+	req.Header.Set("Max-Days", "not-a-number")
+	if _, ok := headerInt(req, "Max-Days"); ok {
+		t.Errorf("headerInt(non-numeric header) ok = true, want false")
+	}
+}
+
+func TestResolveExpiry(t *testing.T) {
+	t.Run("no caps, no request headers", func(t *testing.T) {
+		handler := &ShareXHandler{}
+		req := httptest.NewRequest(http.MethodPost, "/upload", nil)
+		expiresAt, maxDownloads := handler.resolveExpiry(req)
+		if !expiresAt.IsZero() || maxDownloads != 0 {
+			t.Errorf("resolveExpiry = (%v, %v), want (zero, 0)", expiresAt, maxDownloads)
+		}
+	})
+
+	t.Run("request TTL within server cap", func(t *testing.T) {
+		handler := &ShareXHandler{MaxTTL: 30 * 24 * time.Hour, MaxDownloads: 100}
+		req := httptest.NewRequest(http.MethodPost, "/upload", nil)
+		req.Header.Set("Max-Days", "1")
+		req.Header.Set("Max-Downloads", "5")
+		expiresAt, maxDownloads := handler.resolveExpiry(req)
+		wantAt := time.Now().Add(24 * time.Hour)
+		if expiresAt.Sub(wantAt) > time.Minute || expiresAt.Sub(wantAt) < -time.Minute {
+			t.Errorf("resolveExpiry expiresAt = %v, want close to %v", expiresAt, wantAt)
+		}
+		if maxDownloads != 5 {
+			t.Errorf("resolveExpiry maxDownloads = %v, want 5", maxDownloads)
+		}
+	})
+
+	t.Run("request TTL clamped to server MaxTTL", func(t *testing.T) {
+		handler := &ShareXHandler{MaxTTL: time.Hour}
+		req := httptest.NewRequest(http.MethodPost, "/upload", nil)
+		req.Header.Set("Max-Days", "30")
+		expiresAt, _ := handler.resolveExpiry(req)
+		wantAt := time.Now().Add(time.Hour)
+		if expiresAt.After(wantAt.Add(time.Minute)) {
+			t.Errorf("resolveExpiry expiresAt = %v, want clamped to around %v", expiresAt, wantAt)
+		}
+	})
+
+	t.Run("server MaxTTL applies even with no request TTL", func(t *testing.T) {
+		handler := &ShareXHandler{MaxTTL: time.Hour}
+		req := httptest.NewRequest(http.MethodPost, "/upload", nil)
+		expiresAt, _ := handler.resolveExpiry(req)
+		if expiresAt.IsZero() {
+			t.Errorf("resolveExpiry expiresAt = zero, want capped at server MaxTTL")
+		}
+	})
+
+	t.Run("request MaxDownloads clamped to server cap", func(t *testing.T) {
+		handler := &ShareXHandler{MaxDownloads: 10}
+		req := httptest.NewRequest(http.MethodPost, "/upload", nil)
+		req.Header.Set("Max-Downloads", "1000")
+		_, maxDownloads := handler.resolveExpiry(req)
+		if maxDownloads != 10 {
+			t.Errorf("resolveExpiry maxDownloads = %v, want clamped to 10", maxDownloads)
+		}
+	})
+
+	t.Run("server MaxDownloads applies even with no request cap", func(t *testing.T) {
+		handler := &ShareXHandler{MaxDownloads: 10}
+		req := httptest.NewRequest(http.MethodPost, "/upload", nil)
+		_, maxDownloads := handler.resolveExpiry(req)
+		if maxDownloads != 10 {
+			t.Errorf("resolveExpiry maxDownloads = %v, want 10", maxDownloads)
+		}
+	})
+}
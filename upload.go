@@ -0,0 +1,55 @@
+package sharexhandler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+)
+
+// sniffLen is the number of bytes buffered to run http.DetectContentType when a part didn't
+// declare its own Content-Type.
+const sniffLen = 512
+
+// errUploadTooLarge is returned by copyPart when the part exceeds the handler's MaxUploadSize.
+var errUploadTooLarge = errors.New("sharexhandler: upload exceeds MaxUploadSize")
+
+// newHasher returns the hash used to compute a file's strong ETag, defaulting to sha256.
+func (shareXHandler *ShareXHandler) newHasher() hash.Hash {
+	if shareXHandler.HashAlgorithm != nil {
+		return shareXHandler.HashAlgorithm()
+	}
+	return sha256.New()
+}
+
+// copyPart streams part into w, enforcing MaxUploadSize and hashing the content to produce a
+// strong ETag as it goes. If contentType is empty, the first sniffLen bytes are sniffed with
+// http.DetectContentType and the detected type is returned in sniffedContentType.
+func (shareXHandler *ShareXHandler) copyPart(w io.Writer, part io.Reader, contentType string) (sniffedContentType string, etag string, err error) {
+	var source io.Reader = part
+	if shareXHandler.MaxUploadSize > 0 {
+		source = io.LimitReader(part, shareXHandler.MaxUploadSize+1)
+	}
+
+	if contentType == "" {
+		sniffBuf, err := io.ReadAll(io.LimitReader(source, sniffLen))
+		if err != nil {
+			return "", "", err
+		}
+		sniffedContentType = http.DetectContentType(sniffBuf)
+		source = io.MultiReader(bytes.NewReader(sniffBuf), source)
+	}
+
+	hasher := shareXHandler.newHasher()
+	written, err := io.Copy(io.MultiWriter(w, hasher), source)
+	if err != nil {
+		return sniffedContentType, "", err
+	}
+	if shareXHandler.MaxUploadSize > 0 && written > shareXHandler.MaxUploadSize {
+		return sniffedContentType, "", errUploadTooLarge
+	}
+	return sniffedContentType, hex.EncodeToString(hasher.Sum(nil)), nil
+}
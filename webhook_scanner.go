@@ -0,0 +1,61 @@
+package sharexhandler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// WebhookScanner scans uploads by POSTing their content to a third-party HTTP endpoint, such as
+// VirusTotal or a custom in-house scanning service. The endpoint must respond with a JSON body
+// matching webhookScanResponse.
+type WebhookScanner struct {
+	// URL is the endpoint content is POSTed to.
+	URL string
+	// Header is sent with every request, typically used for an API key.
+	Header http.Header
+	// Client is used to make the request. Defaults to http.DefaultClient if nil.
+	Client *http.Client
+}
+
+type webhookScanResponse struct {
+	Infected  bool   `json:"infected"`
+	Signature string `json:"signature"`
+}
+
+func (scanner *WebhookScanner) Scan(ctx context.Context, reader io.Reader) (ScanResult, error) {
+	client := scanner.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, scanner.URL, reader)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	for key, values := range scanner.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ScanResult{}, fmt.Errorf("sharexhandler: scan webhook returned status %v", resp.StatusCode)
+	}
+
+	var parsed webhookScanResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return ScanResult{}, err
+	}
+	if parsed.Infected {
+		return ScanResult{Status: ScanStatusInfected, Signature: parsed.Signature}, nil
+	}
+	return ScanResult{Status: ScanStatusClean}, nil
+}